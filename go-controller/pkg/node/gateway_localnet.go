@@ -3,18 +3,30 @@
 package node
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"k8s.io/client-go/tools/cache"
 	"net"
+	"os/exec"
 	"reflect"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/coreos/go-iptables/iptables"
+	"github.com/ishidawataru/sctp"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/factory"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/kube"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	utilnet "k8s.io/utils/net"
 	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/util/async"
 
 	kapi "k8s.io/api/core/v1"
 )
@@ -42,22 +54,37 @@ type iptRule struct {
 	args  []string
 }
 
-func localnetGatewayIP() string {
+// enabledFamilies returns false for every enabled IPv4 mode and true for
+// every enabled IPv6 mode, in that order. On a dual-stack node both are
+// returned, so callers can concurrently drive a v4 and a v6 code path
+// instead of picking a single family for the whole node.
+func enabledFamilies() []bool {
+	var families []bool
+	if config.IPv4Mode {
+		families = append(families, false)
+	}
 	if config.IPv6Mode {
+		families = append(families, true)
+	}
+	return families
+}
+
+func localnetGatewayIPForFamily(isIPv6 bool) string {
+	if isIPv6 {
 		return v6localnetGatewayIP
 	}
 	return v4localnetGatewayIP
 }
 
-func localnetGatewayNextHop() string {
-	if config.IPv6Mode {
+func localnetGatewayNextHopForFamily(isIPv6 bool) string {
+	if isIPv6 {
 		return v6localnetGatewayNextHop
 	}
 	return v4localnetGatewayNextHop
 }
 
-func localnetGatewayNextHopSubnet() string {
-	if config.IPv6Mode {
+func localnetGatewayNextHopSubnetForFamily(isIPv6 bool) string {
+	if isIPv6 {
 		return v6localnetGatewayNextHopSubnet
 	}
 	return v4localnetGatewayNextHopSubnet
@@ -140,7 +167,7 @@ func localnetGatewayNAT(ipt util.IPTablesHelper, ifname, ip string) error {
 }
 
 func initLocalnetGateway(nodeName string, subnet string, wf *factory.WatchFactory, nodeAnnotator kube.Annotator) error {
-	ipt, err := localnetIPTablesHelper()
+	ipts, err := localnetIPTablesHelpers()
 	if err != nil {
 		return err
 	}
@@ -179,16 +206,25 @@ func initLocalnetGateway(nodeName string, subnet string, wf *factory.WatchFactor
 		return err
 	}
 
-	// Flush any addresses on localnetBridgeNextHopPort and add the new IP address.
-	if err = util.LinkAddrFlush(link); err == nil {
-		err = util.LinkAddrAdd(link, localnetGatewayNextHopSubnet())
-	}
-	if err != nil {
+	// Flush any addresses on localnetBridgeNextHopPort and assign the gateway
+	// next-hop subnet for every enabled IP family, so a dual-stack node gets
+	// both a 169.254.33.1/24 and a fd99::1/64 address on the same port.
+	if err = util.LinkAddrFlush(link); err != nil {
 		return err
 	}
+	var gatewayIPs, nextHops []string
+	for _, isIPv6 := range enabledFamilies() {
+		if err = util.LinkAddrAdd(link, localnetGatewayNextHopSubnetForFamily(isIPv6)); err != nil {
+			return err
+		}
+		gatewayIPs = append(gatewayIPs, localnetGatewayIPForFamily(isIPv6))
+		nextHops = append(nextHops, localnetGatewayNextHopForFamily(isIPv6))
+	}
 
+	// Publish one gateway IP/next-hop pair per enabled IP family so the
+	// master side can install logical flows for both on a dual-stack node.
 	err = util.SetLocalL3GatewayConfig(nodeAnnotator, ifaceID, macAddress,
-		localnetGatewayIP(), localnetGatewayNextHop(),
+		gatewayIPs, nextHops,
 		config.Gateway.NodeportEnable)
 	if err != nil {
 		return err
@@ -205,151 +241,684 @@ func initLocalnetGateway(nodeName string, subnet string, wf *factory.WatchFactor
 		}
 	}
 
-	err = localnetGatewayNAT(ipt, localnetGatewayNextHopPort, localnetGatewayIP())
-	if err != nil {
-		return fmt.Errorf("Failed to add NAT rules for localnet gateway (%v)", err)
+	for isIPv6, ipt := range ipts {
+		if err := localnetGatewayNAT(ipt, localnetGatewayNextHopPort, localnetGatewayIPForFamily(isIPv6)); err != nil {
+			return fmt.Errorf("Failed to add NAT rules for localnet gateway (%v)", err)
+		}
 	}
 
 	if config.Gateway.NodeportEnable {
-		err = localnetNodePortWatcher(ipt, wf)
+		err = localnetNodePortWatcher(ipts, wf)
 	}
 
 	return err
 }
 
-func localnetIptRules(svc *kapi.Service) []iptRule {
-	rules := make([]iptRule, 0)
-	for _, svcPort := range svc.Spec.Ports {
-		protocol := svcPort.Protocol
-		if protocol != kapi.ProtocolUDP && protocol != kapi.ProtocolTCP {
-			protocol = kapi.ProtocolTCP
+// serviceHasClusterIP returns true if svc has a real (non-headless) ClusterIP,
+// which is the precondition kube-proxy uses before programming ExternalIPs.
+func serviceHasClusterIP(svc *kapi.Service) bool {
+	return svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != kapi.ClusterIPNone
+}
+
+// isLocalAddr returns whether ip is already assigned to one of the node's
+// interfaces, per the localAddrs snapshot returned by getLocalAddrs().
+func isLocalAddr(ip string, localAddrs map[string]net.IPNet) bool {
+	_, found := localAddrs[ip]
+	return found
+}
+
+// foreignExternalIPs returns the subset of svc.Spec.ExternalIPs that are not
+// already assigned to a local interface. Traffic to these IPs only reaches
+// the node via DNAT from another host, but traffic originated by the node
+// itself needs an extra host route to be captured by that same DNAT rule.
+func foreignExternalIPs(svc *kapi.Service, localAddrs map[string]net.IPNet) []string {
+	var foreign []string
+	if !serviceHasClusterIP(svc) {
+		return foreign
+	}
+	for _, extIP := range svc.Spec.ExternalIPs {
+		if !isLocalAddr(extIP, localAddrs) {
+			foreign = append(foreign, extIP)
 		}
+	}
+	return foreign
+}
 
-		nodePort := fmt.Sprintf("%d", svcPort.NodePort)
-		destination := net.JoinHostPort(strings.Split(localnetGatewayIP(), "/")[0], nodePort)
-
-		rules = append(rules, iptRule{
-			table: "nat",
-			chain: iptableNodePortChain,
-			args: []string{
-				"-p", string(protocol), "--dport", nodePort,
-				"-j", "DNAT", "--to-destination", destination,
-			},
-		})
-		rules = append(rules, iptRule{
-			table: "filter",
-			chain: iptableNodePortChain,
-			args: []string{
-				"-p", string(protocol), "--dport", nodePort,
-				"-j", "ACCEPT",
-			},
-		})
+// getLocalAddrs returns a snapshot of the IP addresses currently assigned to
+// the node, keyed by address string, so callers can tell a locally-owned
+// ExternalIP apart from a foreign one without re-querying netlink per call.
+func getLocalAddrs() (map[string]net.IPNet, error) {
+	localAddrSet := make(map[string]net.IPNet)
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node IP addresses: %v", err)
+	}
+	for _, addr := range addrs {
+		localAddrSet[addr.IP.String()] = *addr.IPNet
+	}
+	return localAddrSet, nil
+}
+
+// normalizeProtocol coerces any protocol besides TCP/UDP/SCTP to TCP, same
+// as kube-proxy's default. Shared by the rule and port-reservation-tuple
+// generators so a port's reservation key and iptables rule key never
+// diverge over an unnormalized Protocol.
+func normalizeProtocol(protocol kapi.Protocol) kapi.Protocol {
+	if protocol != kapi.ProtocolUDP && protocol != kapi.ProtocolTCP && protocol != kapi.ProtocolSCTP {
+		return kapi.ProtocolTCP
+	}
+	return protocol
+}
+
+// protoMatchArgs returns the "-p <protocol> -m <protocol>" args iptables-save
+// reports for a rule, even though only "-p" is required to write one: the
+// kernel always makes the implicit "-m" match explicit, so rules generated
+// here must include it too or they'll never match their own on-disk form.
+func protoMatchArgs(protocol kapi.Protocol) []string {
+	proto := strings.ToLower(string(protocol))
+	return []string{"-p", proto, "-m", proto}
+}
+
+// normalizeDestIP appends the host prefix length ("/32" or "/128") that
+// iptables-save reports for a bare address, so "-d" args generated here key
+// identically to the ones read back from the kernel.
+func normalizeDestIP(ip string, isIPv6 bool) string {
+	if isIPv6 {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+// localnetIptRules computes the DNAT/ACCEPT rules for svc, keyed by address
+// family (false=IPv4, true=IPv6). NodePort rules, which aren't tied to a
+// specific address, are generated once per enabled family; ExternalIP and
+// LoadBalancer ingress rules are generated only under the family of the
+// address itself, so a v4-only service on a dual-stack node lands only in
+// iptables and a v6-only one only in ip6tables.
+func localnetIptRules(svc *kapi.Service, localAddrs map[string]net.IPNet) map[bool][]iptRule {
+	rulesByFamily := make(map[bool][]iptRule)
+	hasClusterIP := serviceHasClusterIP(svc)
+	families := enabledFamilies()
 
+	for _, svcPort := range svc.Spec.Ports {
+		protocol := normalizeProtocol(svcPort.Protocol)
+		nodePort := fmt.Sprintf("%d", svcPort.NodePort)
 		ingPort := fmt.Sprintf("%d", svcPort.Port)
+
+		if svcPort.NodePort != 0 {
+			for _, isIPv6 := range families {
+				destination := net.JoinHostPort(strings.Split(localnetGatewayIPForFamily(isIPv6), "/")[0], nodePort)
+				rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
+					table: "nat",
+					chain: iptableNodePortChain,
+					args: append(protoMatchArgs(protocol), "--dport", nodePort,
+						"-j", "DNAT", "--to-destination", destination),
+				})
+				rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
+					table: "filter",
+					chain: iptableNodePortChain,
+					args: append(protoMatchArgs(protocol), "--dport", nodePort,
+						"-j", "ACCEPT"),
+				})
+			}
+		}
+
 		for _, ing := range svc.Status.LoadBalancer.Ingress {
 			if ing.IP == "" {
 				continue
 			}
-			rules = append(rules, iptRule{
+			isIPv6 := utilnet.IsIPv6String(ing.IP)
+			destination := net.JoinHostPort(strings.Split(localnetGatewayIPForFamily(isIPv6), "/")[0], nodePort)
+			rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
 				table: "nat",
 				chain: iptableNodePortChain,
-				args: []string{
-					"-d", ing.IP,
-					"-p", string(protocol), "--dport", ingPort,
-					"-j", "DNAT", "--to-destination", destination,
-				},
+				args: append([]string{"-d", normalizeDestIP(ing.IP, isIPv6)}, append(protoMatchArgs(protocol),
+					"--dport", ingPort, "-j", "DNAT", "--to-destination", destination)...),
 			})
-			rules = append(rules, iptRule{
+			rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
 				table: "filter",
 				chain: iptableNodePortChain,
-				args: []string{
-					"-d", ing.IP,
-					"-p", string(protocol), "--dport", ingPort,
-					"-j", "ACCEPT",
-				},
+				args: append([]string{"-d", normalizeDestIP(ing.IP, isIPv6)}, append(protoMatchArgs(protocol),
+					"--dport", ingPort, "-j", "ACCEPT")...),
 			})
 		}
+
+		if hasClusterIP {
+			for _, extIP := range svc.Spec.ExternalIPs {
+				isIPv6 := utilnet.IsIPv6String(extIP)
+				destination := net.JoinHostPort(strings.Split(localnetGatewayIPForFamily(isIPv6), "/")[0], nodePort)
+				rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
+					table: "nat",
+					chain: iptableNodePortChain,
+					args: append([]string{"-d", normalizeDestIP(extIP, isIPv6)}, append(protoMatchArgs(protocol),
+						"--dport", ingPort, "-j", "DNAT", "--to-destination", destination)...),
+				})
+				rulesByFamily[isIPv6] = append(rulesByFamily[isIPv6], iptRule{
+					table: "filter",
+					chain: iptableNodePortChain,
+					args: append([]string{"-d", normalizeDestIP(extIP, isIPv6)}, append(protoMatchArgs(protocol),
+						"--dport", ingPort, "-j", "ACCEPT")...),
+				})
+			}
+		}
 	}
-	return rules
+	return rulesByFamily
 }
 
-// localnetIPTablesHelper gets an IPTablesHelper for IPv4 or IPv6 as appropriate
-func localnetIPTablesHelper() (util.IPTablesHelper, error) {
-	var ipt util.IPTablesHelper
-	var err error
-	if config.IPv6Mode {
-		ipt, err = util.GetIPTablesHelper(iptables.ProtocolIPv6)
-	} else {
-		ipt, err = util.GetIPTablesHelper(iptables.ProtocolIPv4)
+// key uniquely identifies an iptRule for diffing purposes.
+func (r iptRule) key() string {
+	return r.table + "/" + r.chain + "/" + strings.Join(r.args, " ")
+}
+
+// hostRoutes converts a list of external IPs into the /32 (or /128) subnets
+// that util.LinkRoutesAdd/LinkRoutesDel expect, one per IP: /32 for an IPv4
+// address and /128 for an IPv6 one. Unparseable entries are skipped.
+func hostRoutes(ips []string) []*net.IPNet {
+	var subnets []*net.IPNet
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			continue
+		}
+		mask := net.CIDRMask(32, 32)
+		if utilnet.IsIPv6(parsed) {
+			mask = net.CIDRMask(128, 128)
+		}
+		subnets = append(subnets, &net.IPNet{IP: parsed, Mask: mask})
+	}
+	return subnets
+}
+
+// addExternalIPRoutes adds a host route for every ExternalIP of svc that
+// isn't already assigned to a local interface, so that traffic originated by
+// the node itself for that ExternalIP is also captured by the DNAT rules
+// added by localnetIptRules.
+func addExternalIPRoutes(svc *kapi.Service, localAddrs map[string]net.IPNet) error {
+	link, err := util.LinkSetUp(localnetGatewayNextHopPort)
+	if err != nil {
+		return err
 	}
+	subnets := hostRoutes(foreignExternalIPs(svc, localAddrs))
+	if len(subnets) == 0 {
+		return nil
+	}
+	if err := util.LinkRoutesAdd(link, subnets); err != nil {
+		return fmt.Errorf("failed to add host routes for external IPs of service %s/%s: %v", svc.Namespace, svc.Name, err)
+	}
+	return nil
+}
+
+// delExternalIPRoutes removes the host routes added by addExternalIPRoutes.
+func delExternalIPRoutes(svc *kapi.Service, localAddrs map[string]net.IPNet) {
+	link, err := util.LinkSetUp(localnetGatewayNextHopPort)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize iptables: %v", err)
+		klog.Errorf("Failed to look up gateway link while removing external IP routes: %v", err)
+		return
+	}
+	subnets := hostRoutes(foreignExternalIPs(svc, localAddrs))
+	if len(subnets) == 0 {
+		return
+	}
+	if err := util.LinkRoutesDel(link, subnets); err != nil {
+		klog.Warningf("Failed to delete host routes for external IPs of service %s/%s: %v", svc.Namespace, svc.Name, err)
 	}
-	return ipt, nil
 }
 
-// AddService adds service and creates corresponding resources in OVN
-func localnetAddService(svc *kapi.Service) error {
-	if !util.ServiceTypeHasNodePort(svc) {
-		return nil
+// portTuple is a single (ip, port, protocol) combination that localnetSyncServices
+// programs an iptables rule for. ip is the wildcard address ("0.0.0.0" or
+// "::") for NodePort tuples, which bind to all of the node's addresses, same
+// as kube-proxy/kubelet do.
+type portTuple struct {
+	ip       string
+	port     int32
+	protocol kapi.Protocol
+}
+
+// localnetServicePortTuples enumerates every (ip, port, protocol) combination
+// that localnetIptRules programs a DNAT/ACCEPT rule for, so the port
+// reservation subsystem below can open a matching listening socket for each.
+// NodePort tuples get a wildcard address per enabled IP family, same as
+// kube-proxy binding 0.0.0.0 and [::] separately on a dual-stack node.
+func localnetServicePortTuples(svc *kapi.Service) []portTuple {
+	var tuples []portTuple
+	hasClusterIP := serviceHasClusterIP(svc)
+	for _, svcPort := range svc.Spec.Ports {
+		protocol := normalizeProtocol(svcPort.Protocol)
+		if svcPort.NodePort != 0 {
+			for _, isIPv6 := range enabledFamilies() {
+				wildcard := "0.0.0.0"
+				if isIPv6 {
+					wildcard = "::"
+				}
+				tuples = append(tuples, portTuple{ip: wildcard, port: svcPort.NodePort, protocol: protocol})
+			}
+		}
+		for _, ing := range svc.Status.LoadBalancer.Ingress {
+			if ing.IP != "" {
+				tuples = append(tuples, portTuple{ip: ing.IP, port: svcPort.Port, protocol: protocol})
+			}
+		}
+		if hasClusterIP {
+			for _, extIP := range svc.Spec.ExternalIPs {
+				tuples = append(tuples, portTuple{ip: extIP, port: svcPort.Port, protocol: protocol})
+			}
+		}
+	}
+	return tuples
+}
+
+// portReservationKey identifies a single reserved listening socket.
+type portReservationKey struct {
+	svcKey   string
+	protocol kapi.Protocol
+	ip       string
+	port     int32
+}
+
+func (t portTuple) reservationKey(svcKey string) portReservationKey {
+	return portReservationKey{svcKey: svcKey, protocol: t.protocol, ip: t.ip, port: t.port}
+}
+
+var (
+	portReservationsLock sync.Mutex
+	portReservations     = make(map[portReservationKey]io.Closer)
+)
+
+// openLocalPort binds a listening socket to tuple, mirroring what kube-proxy
+// does for NodePort/ExternalIP/LoadBalancer addresses: holding the socket
+// open prevents a hostNetwork pod or system daemon from stealing the port
+// out from under our iptables DNAT rule.
+func openLocalPort(t portTuple) (io.Closer, error) {
+	switch t.protocol {
+	case kapi.ProtocolUDP:
+		return net.ListenPacket("udp", net.JoinHostPort(t.ip, fmt.Sprintf("%d", t.port)))
+	case kapi.ProtocolSCTP:
+		addr, err := sctp.ResolveSCTPAddr("sctp", net.JoinHostPort(t.ip, fmt.Sprintf("%d", t.port)))
+		if err != nil {
+			return nil, err
+		}
+		return sctp.ListenSCTP("sctp", addr)
+	default:
+		return net.Listen("tcp", net.JoinHostPort(t.ip, fmt.Sprintf("%d", t.port)))
+	}
+}
+
+// reserveServicePorts opens a listening socket for every tuple of svc that
+// isn't already reserved. EADDRINUSE is logged and otherwise ignored: the
+// iptables rule is still programmed, so traffic is still redirected, we just
+// lose the anti-conflict guarantee for that one tuple.
+func reserveServicePorts(svc *kapi.Service) {
+	svcKey := svc.Namespace + "/" + svc.Name
+	tuples := localnetServicePortTuples(svc)
+
+	portReservationsLock.Lock()
+	defer portReservationsLock.Unlock()
+
+	for _, t := range tuples {
+		key := t.reservationKey(svcKey)
+		if _, held := portReservations[key]; held {
+			continue
+		}
+		closer, err := openLocalPort(t)
+		if err != nil {
+			if errors.Is(err, syscall.EADDRINUSE) {
+				klog.Warningf("Port %s:%d/%s for service %s is already in use, leaving iptables rule in place", t.ip, t.port, t.protocol, svcKey)
+				continue
+			}
+			klog.Errorf("Failed to reserve port %s:%d/%s for service %s: %v", t.ip, t.port, t.protocol, svcKey, err)
+			continue
+		}
+		portReservations[key] = closer
+	}
+}
+
+// releaseServicePorts closes every socket currently reserved for svc.
+func releaseServicePorts(svc *kapi.Service) {
+	reconcileServicePorts(svc, nil)
+}
+
+// reconcileServicePorts closes reservations for svc that are no longer in
+// wantTuples and leaves the rest alone, so a service update only tears down
+// the sockets whose tuple actually went away.
+func reconcileServicePorts(svc *kapi.Service, wantTuples []portTuple) {
+	svcKey := svc.Namespace + "/" + svc.Name
+	want := make(map[portReservationKey]bool, len(wantTuples))
+	for _, t := range wantTuples {
+		want[t.reservationKey(svcKey)] = true
+	}
+
+	portReservationsLock.Lock()
+	defer portReservationsLock.Unlock()
+
+	for key, closer := range portReservations {
+		if key.svcKey != svcKey || want[key] {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			klog.Warningf("Failed to close reserved port %s:%d/%s for service %s: %v", key.ip, key.port, key.protocol, svcKey, err)
+		}
+		delete(portReservations, key)
 	}
-	ipt, err := localnetIPTablesHelper()
+}
+
+var sctpModprobeOnce sync.Once
+
+// serviceHasSCTPPort returns true if any port of svc uses the SCTP protocol.
+func serviceHasSCTPPort(svc *kapi.Service) bool {
+	for _, p := range svc.Spec.Ports {
+		if p.Protocol == kapi.ProtocolSCTP {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureSCTPModuleLoaded lazily loads the xt_sctp netfilter module the first
+// time an SCTP rule needs to be programmed. Loading it unconditionally at
+// package init would fail (and spam logs) on hosts that never see an SCTP
+// service, and every iptables -p sctp match depends on it being present.
+func ensureSCTPModuleLoaded() {
+	sctpModprobeOnce.Do(func() {
+		if out, err := exec.Command("modprobe", "xt_sctp").CombinedOutput(); err != nil {
+			klog.Warningf("Failed to load xt_sctp kernel module: %v: %s", err, out)
+		}
+	})
+}
+
+// localnetIPTablesHelpers gets an IPTablesHelper for every enabled IP family,
+// keyed by isIPv6, so a dual-stack node can drive iptables and ip6tables
+// concurrently instead of picking a single family for the whole node.
+func localnetIPTablesHelpers() (map[bool]util.IPTablesHelper, error) {
+	ipts := make(map[bool]util.IPTablesHelper)
+	for _, isIPv6 := range enabledFamilies() {
+		proto := iptables.ProtocolIPv4
+		if isIPv6 {
+			proto = iptables.ProtocolIPv6
+		}
+		ipt, err := util.GetIPTablesHelper(proto)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize iptables for family %s: %v", protocolName(isIPv6), err)
+		}
+		ipts[isIPv6] = ipt
+	}
+	return ipts, nil
+}
+
+func protocolName(isIPv6 bool) string {
+	if isIPv6 {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+const (
+	// nodePortSyncMinPeriod is the minimum time between two consecutive
+	// OVN-KUBE-NODEPORT resyncs, so a burst of service churn coalesces into
+	// a single iptables-restore transaction instead of one shell-out per rule.
+	nodePortSyncMinPeriod = 2 * time.Second
+	// nodePortSyncMaxPeriod is the longest we'll go without a resync even if
+	// nothing enqueues work, to self-heal from rules changed out-of-band.
+	nodePortSyncMaxPeriod = 30 * time.Second
+	nodePortSyncBurst     = 2
+)
+
+// localnetStaticIptRules are the always-present jump rules that divert
+// NodePort/ExternalIP/LoadBalancer traffic into OVN-KUBE-NODEPORT. They are
+// idempotently ensured once at watcher start and never touched by the
+// per-service sync.
+func localnetStaticIptRules() []iptRule {
+	return []iptRule{
+		{table: "nat", chain: "PREROUTING", args: []string{"-j", iptableNodePortChain}},
+		{table: "nat", chain: "OUTPUT", args: []string{"-j", iptableNodePortChain}},
+		{table: "filter", chain: "FORWARD", args: []string{"-j", iptableNodePortChain}},
+	}
+}
+
+// localnetServiceCache is the node-local view of every service currently
+// seen by the watch factory, keyed by namespace/name. The bounded frequency
+// runner rebuilds the desired OVN-KUBE-NODEPORT contents from this cache on
+// every resync, instead of the per-event ClearChain-then-readd this replaces.
+type localnetServiceCache struct {
+	lock     sync.Mutex
+	services map[string]*kapi.Service
+}
+
+func newLocalnetServiceCache() *localnetServiceCache {
+	return &localnetServiceCache{services: make(map[string]*kapi.Service)}
+}
+
+func (c *localnetServiceCache) set(svc *kapi.Service) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.services[svc.Namespace+"/"+svc.Name] = svc
+}
+
+func (c *localnetServiceCache) delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.services, key)
+}
+
+func (c *localnetServiceCache) list() []*kapi.Service {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	svcs := make([]*kapi.Service, 0, len(c.services))
+	for _, svc := range c.services {
+		svcs = append(svcs, svc)
+	}
+	return svcs
+}
+
+// iptablesSaveCommand returns the iptables-save binary for the address family.
+func iptablesSaveCommand(isIPv6 bool) string {
+	if isIPv6 {
+		return "ip6tables-save"
+	}
+	return "iptables-save"
+}
+
+func iptablesRestoreCommand(isIPv6 bool) string {
+	if isIPv6 {
+		return "ip6tables-restore"
+	}
+	return "iptables-restore"
+}
+
+// currentChainRules returns the argument list of every "-A chain ..." line
+// iptables-save reports for chain in table.
+func currentChainRules(isIPv6 bool, table, chain string) ([][]string, error) {
+	saveCmd := iptablesSaveCommand(isIPv6)
+	out, err := exec.Command(saveCmd, "-t", table).CombinedOutput()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to run %s -t %s: %v: %s", saveCmd, table, err, out)
 	}
-	rules := localnetIptRules(svc)
-	klog.V(5).Infof("Add rules %v for service %v", rules, svc.Name)
-	return addIptRules(ipt, rules)
+	prefix := "-A " + chain + " "
+	var rules [][]string
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		rules = append(rules, strings.Fields(strings.TrimPrefix(line, prefix)))
+	}
+	return rules, nil
 }
 
-func localnetDeleteService(svc *kapi.Service) error {
-	if !util.ServiceTypeHasNodePort(svc) {
+// applyIptablesRestore issues a single iptables-restore (or ip6tables-restore,
+// for isIPv6) transaction for table containing only the delta between what's
+// live and what's desired.
+func applyIptablesRestore(isIPv6 bool, table string, toAdd, toDel []iptRule) error {
+	if len(toAdd) == 0 && len(toDel) == 0 {
 		return nil
 	}
-	ipt, err := localnetIPTablesHelper()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s\n", table)
+	for _, r := range toDel {
+		fmt.Fprintf(&b, "-D %s %s\n", r.chain, strings.Join(r.args, " "))
+	}
+	for _, r := range toAdd {
+		fmt.Fprintf(&b, "-A %s %s\n", r.chain, strings.Join(r.args, " "))
+	}
+	b.WriteString("COMMIT\n")
+
+	restoreCmd := iptablesRestoreCommand(isIPv6)
+	cmd := exec.Command(restoreCmd, "--noflush")
+	cmd.Stdin = strings.NewReader(b.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply %s for table %s: %v: %s", restoreCmd, table, err, out)
+	}
+	return nil
+}
+
+// syncNodePortChain diffs the live contents of OVN-KUBE-NODEPORT in table,
+// for the given address family, against desired and applies only the delta.
+func syncNodePortChain(ipt util.IPTablesHelper, isIPv6 bool, table string, desired []iptRule) error {
+	if err := ensureChain(ipt, table, iptableNodePortChain); err != nil {
+		return err
+	}
+
+	current, err := currentChainRules(isIPv6, table, iptableNodePortChain)
 	if err != nil {
 		return err
 	}
-	rules := localnetIptRules(svc)
-	klog.V(5).Infof("Delete rules %v for service %v", rules, svc.Name)
-	delIptRules(ipt, rules)
-	return nil
+
+	toAdd, toDel := diffIptRules(table, iptableNodePortChain, current, desired)
+
+	return applyIptablesRestore(isIPv6, table, toAdd, toDel)
 }
 
-func localnetNodePortWatcher(ipt util.IPTablesHelper, wf *factory.WatchFactory) error {
-	// delete all the existing OVN-NODEPORT rules
-	// TODO: Add a localnetSyncService method to remove the stale entries only
-	_ = ipt.ClearChain("nat", iptableNodePortChain)
-	_ = ipt.ClearChain("filter", iptableNodePortChain)
+// diffIptRules compares currentArgs (the raw "-A chain ..." argument lists
+// reported by iptables-save for table/chain) against desired and returns the
+// rules that need to be added and removed to reconcile the two, so the
+// iptables-restore transaction built from them touches only the delta.
+func diffIptRules(table, chain string, currentArgs [][]string, desired []iptRule) (toAdd, toDel []iptRule) {
+	desiredSet := make(map[string]bool, len(desired))
+	for _, r := range desired {
+		desiredSet[r.key()] = true
+	}
 
-	rules := make([]iptRule, 0)
-	rules = append(rules, iptRule{
-		table: "nat",
-		chain: "PREROUTING",
-		args:  []string{"-j", iptableNodePortChain},
-	})
-	rules = append(rules, iptRule{
-		table: "nat",
-		chain: "OUTPUT",
-		args:  []string{"-j", iptableNodePortChain},
-	})
-	rules = append(rules, iptRule{
-		table: "filter",
-		chain: "FORWARD",
-		args:  []string{"-j", iptableNodePortChain},
-	})
+	currentRules := make([]iptRule, 0, len(currentArgs))
+	currentSet := make(map[string]bool, len(currentArgs))
+	for _, args := range currentArgs {
+		r := iptRule{table: table, chain: chain, args: args}
+		currentRules = append(currentRules, r)
+		currentSet[r.key()] = true
+	}
+
+	for _, r := range desired {
+		if !currentSet[r.key()] {
+			toAdd = append(toAdd, r)
+		}
+	}
+	for _, r := range currentRules {
+		if !desiredSet[r.key()] {
+			toDel = append(toDel, r)
+		}
+	}
+	return toAdd, toDel
+}
 
-	if err := addIptRules(ipt, rules); err != nil {
+// needsLocalnetRules returns true if svc should have DNAT/ACCEPT rules
+// programmed in OVN-KUBE-NODEPORT: either it's a NodePort/LoadBalancer
+// service, or it's a ClusterIP service with spec.ExternalIPs set. A plain
+// ClusterIP service with no ExternalIPs never reaches the node via this
+// chain and is skipped. This is a Type-level check only: the protocol of
+// svc's ports (TCP/UDP/SCTP) plays no part in it, so an SCTP-only service
+// is gated exactly the same as a TCP one.
+func needsLocalnetRules(svc *kapi.Service) bool {
+	return util.ServiceTypeHasNodePort(svc) || (serviceHasClusterIP(svc) && len(svc.Spec.ExternalIPs) > 0)
+}
+
+// localnetSyncServices reconciles OVN-KUBE-NODEPORT with exactly the rules
+// implied by services, replacing the old ClearChain-then-readd bootstrap
+// with a targeted diff against the live iptables state of every enabled IP
+// family.
+func localnetSyncServices(services []*kapi.Service) error {
+	ipts, err := localnetIPTablesHelpers()
+	if err != nil {
+		return err
+	}
+	localAddrs, err := getLocalAddrs()
+	if err != nil {
 		return err
 	}
 
+	desired := make(map[bool]map[string][]iptRule, len(ipts))
+	for isIPv6 := range ipts {
+		desired[isIPv6] = map[string][]iptRule{"nat": nil, "filter": nil}
+	}
+
+	for _, svc := range services {
+		if !needsLocalnetRules(svc) {
+			continue
+		}
+		if serviceHasSCTPPort(svc) {
+			ensureSCTPModuleLoaded()
+		}
+		for isIPv6, rules := range localnetIptRules(svc, localAddrs) {
+			if _, enabled := ipts[isIPv6]; !enabled {
+				continue
+			}
+			for _, r := range rules {
+				desired[isIPv6][r.table] = append(desired[isIPv6][r.table], r)
+			}
+		}
+	}
+
+	for isIPv6, ipt := range ipts {
+		for _, table := range []string{"nat", "filter"} {
+			if err := syncNodePortChain(ipt, isIPv6, table, desired[isIPv6][table]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func localnetNodePortWatcher(ipts map[bool]util.IPTablesHelper, wf *factory.WatchFactory) error {
+	for _, ipt := range ipts {
+		if err := addIptRules(ipt, localnetStaticIptRules()); err != nil {
+			return err
+		}
+	}
+
+	svcCache := newLocalnetServiceCache()
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "localnet-nodeport")
+
+	runner := async.NewBoundedFrequencyRunner("sync-localnet-nodeport",
+		func() {
+			if err := localnetSyncServices(svcCache.list()); err != nil {
+				klog.Errorf("Failed to sync %s iptables chain: %v", iptableNodePortChain, err)
+			}
+		}, nodePortSyncMinPeriod, nodePortSyncMaxPeriod, nodePortSyncBurst)
+	go runner.Loop(wait.NeverStop)
+
+	go func() {
+		for {
+			key, shutdown := queue.Get()
+			if shutdown {
+				return
+			}
+			runner.Run()
+			queue.Forget(key)
+			queue.Done(key)
+		}
+	}()
+
 	_, err := wf.AddServiceHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			svc := obj.(*kapi.Service)
-			err := localnetAddService(svc)
-			if err != nil {
+			svcCache.set(svc)
+			if serviceHasSCTPPort(svc) {
+				ensureSCTPModuleLoaded()
+			}
+			reserveServicePorts(svc)
+			if localAddrs, err := getLocalAddrs(); err != nil {
+				klog.Errorf("Error in adding service: %v", err)
+			} else if err := addExternalIPRoutes(svc, localAddrs); err != nil {
 				klog.Errorf("Error in adding service: %v", err)
 			}
+			queue.Add(svc.Namespace + "/" + svc.Name)
 		},
 		UpdateFunc: func(old, new interface{}) {
 			svcNew := new.(*kapi.Service)
@@ -358,21 +927,34 @@ func localnetNodePortWatcher(ipt util.IPTablesHelper, wf *factory.WatchFactory)
 				reflect.DeepEqual(svcNew.Status, svcOld.Status) {
 				return
 			}
-			err := localnetDeleteService(svcOld)
-			if err != nil {
-				klog.Errorf("Error in deleting service - %v", err)
+			svcCache.set(svcNew)
+			if serviceHasSCTPPort(svcNew) {
+				ensureSCTPModuleLoaded()
 			}
-			err = localnetAddService(svcNew)
+			localAddrs, err := getLocalAddrs()
 			if err != nil {
-				klog.Errorf("Error in modifying service: %v", err)
+				klog.Errorf("Error in updating service: %v", err)
+			} else {
+				reconcileServicePorts(svcNew, localnetServicePortTuples(svcNew))
+				reserveServicePorts(svcNew)
+				delExternalIPRoutes(svcOld, localAddrs)
+				if err := addExternalIPRoutes(svcNew, localAddrs); err != nil {
+					klog.Errorf("Error in updating service: %v", err)
+				}
 			}
+			queue.Add(svcNew.Namespace + "/" + svcNew.Name)
 		},
 		DeleteFunc: func(obj interface{}) {
 			svc := obj.(*kapi.Service)
-			err := localnetDeleteService(svc)
-			if err != nil {
+			key := svc.Namespace + "/" + svc.Name
+			svcCache.delete(key)
+			releaseServicePorts(svc)
+			if localAddrs, err := getLocalAddrs(); err != nil {
 				klog.Errorf("Error in deleting service - %v", err)
+			} else {
+				delExternalIPRoutes(svc, localAddrs)
 			}
+			queue.Add(key)
 		},
 	}, nil)
 	return err
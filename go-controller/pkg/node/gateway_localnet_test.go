@@ -0,0 +1,248 @@
+// +build linux
+
+package node
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
+	kapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGatewayLocalnet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Gateway Localnet Suite")
+}
+
+// protocolOf returns the argument following "-p" in an iptRule, if any.
+func protocolOf(r iptRule) string {
+	for i, a := range r.args {
+		if a == "-p" && i+1 < len(r.args) {
+			return r.args[i+1]
+		}
+	}
+	return ""
+}
+
+var _ = Describe("localnetIptRules", func() {
+	BeforeEach(func() {
+		config.IPv4Mode = true
+		config.IPv6Mode = false
+	})
+
+	It("emits one DNAT and one ACCEPT rule per protocol for a service with mixed TCP/UDP/SCTP ports", func() {
+		svc := &kapi.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "mixed-proto", Namespace: "default"},
+			Spec: kapi.ServiceSpec{
+				ClusterIP: "10.96.0.10",
+				Ports: []kapi.ServicePort{
+					{Protocol: kapi.ProtocolTCP, Port: 80, NodePort: 30080},
+					{Protocol: kapi.ProtocolUDP, Port: 53, NodePort: 30053},
+					{Protocol: kapi.ProtocolSCTP, Port: 9999, NodePort: 30999},
+				},
+			},
+		}
+
+		rulesByFamily := localnetIptRules(svc, nil)
+		Expect(rulesByFamily).To(HaveLen(1))
+
+		var rules []iptRule
+		for _, familyRules := range rulesByFamily {
+			rules = append(rules, familyRules...)
+		}
+
+		byProtocol := map[string]int{}
+		for _, r := range rules {
+			byProtocol[protocolOf(r)]++
+		}
+
+		Expect(byProtocol["tcp"]).To(Equal(2))
+		Expect(byProtocol["udp"]).To(Equal(2))
+		Expect(byProtocol["sctp"]).To(Equal(2))
+		Expect(rules).To(HaveLen(6))
+	})
+
+	It("dispatches ExternalIP rules by address family on a dual-stack node", func() {
+		config.IPv6Mode = true
+
+		svc := &kapi.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "dual-stack-svc", Namespace: "default"},
+			Spec: kapi.ServiceSpec{
+				ClusterIP:   "10.96.0.11",
+				ExternalIPs: []string{"203.0.113.5", "2001:db8::5"},
+				Ports: []kapi.ServicePort{
+					{Protocol: kapi.ProtocolTCP, Port: 80, NodePort: 30080},
+				},
+			},
+		}
+
+		rulesByFamily := localnetIptRules(svc, nil)
+		Expect(rulesByFamily).To(HaveLen(2))
+
+		for _, r := range rulesByFamily[false] {
+			Expect(strings.Join(r.args, " ")).NotTo(ContainSubstring("2001:db8::5"))
+		}
+		for _, r := range rulesByFamily[true] {
+			Expect(strings.Join(r.args, " ")).NotTo(ContainSubstring("203.0.113.5"))
+		}
+	})
+
+	It("does not emit a NodePort rule for a ClusterIP service with only spec.ExternalIPs set", func() {
+		svc := &kapi.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "externalip-only", Namespace: "default"},
+			Spec: kapi.ServiceSpec{
+				ClusterIP:   "10.96.0.16",
+				ExternalIPs: []string{"203.0.113.12"},
+				Ports: []kapi.ServicePort{
+					{Protocol: kapi.ProtocolTCP, Port: 80},
+				},
+			},
+		}
+
+		rulesByFamily := localnetIptRules(svc, nil)
+		Expect(rulesByFamily).To(HaveLen(1))
+
+		rules := rulesByFamily[false]
+		Expect(rules).To(HaveLen(2))
+		for _, r := range rules {
+			Expect(strings.Join(r.args, " ")).To(ContainSubstring("203.0.113.12"))
+			Expect(strings.Join(r.args, " ")).NotTo(ContainSubstring("--dport 0"))
+		}
+	})
+})
+
+// fakeCloser records whether Close was called so tests can assert which reservations
+// reconcileServicePorts tore down without opening a real socket.
+type fakeCloser struct {
+	closed bool
+}
+
+func (c *fakeCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+var _ = Describe("port reservations", func() {
+	AfterEach(func() {
+		portReservationsLock.Lock()
+		for k := range portReservations {
+			delete(portReservations, k)
+		}
+		portReservationsLock.Unlock()
+	})
+
+	It("enumerates one tuple per NodePort/ExternalIP/LoadBalancer ingress port", func() {
+		config.IPv4Mode = true
+		config.IPv6Mode = false
+
+		svc := &kapi.Service{
+			Spec: kapi.ServiceSpec{
+				ClusterIP:   "10.96.0.14",
+				ExternalIPs: []string{"203.0.113.10"},
+				Ports:       []kapi.ServicePort{{Protocol: kapi.ProtocolTCP, Port: 80, NodePort: 30080}},
+			},
+			Status: kapi.ServiceStatus{
+				LoadBalancer: kapi.LoadBalancerStatus{
+					Ingress: []kapi.LoadBalancerIngress{{IP: "203.0.113.20"}},
+				},
+			},
+		}
+
+		tuples := localnetServicePortTuples(svc)
+		Expect(tuples).To(ConsistOf(
+			portTuple{ip: "0.0.0.0", port: 30080, protocol: kapi.ProtocolTCP},
+			portTuple{ip: "203.0.113.20", port: 80, protocol: kapi.ProtocolTCP},
+			portTuple{ip: "203.0.113.10", port: 80, protocol: kapi.ProtocolTCP},
+		))
+	})
+
+	It("closes only the reservations that dropped out of wantTuples", func() {
+		svc := &kapi.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default"}}
+		svcKey := "default/svc"
+
+		kept := &fakeCloser{}
+		dropped := &fakeCloser{}
+		otherSvc := &fakeCloser{}
+
+		keptTuple := portTuple{ip: "203.0.113.1", port: 80, protocol: kapi.ProtocolTCP}
+		droppedTuple := portTuple{ip: "203.0.113.2", port: 81, protocol: kapi.ProtocolTCP}
+		otherTuple := portTuple{ip: "203.0.113.3", port: 82, protocol: kapi.ProtocolTCP}
+
+		portReservationsLock.Lock()
+		portReservations[keptTuple.reservationKey(svcKey)] = kept
+		portReservations[droppedTuple.reservationKey(svcKey)] = dropped
+		portReservations[otherTuple.reservationKey("default/other")] = otherSvc
+		portReservationsLock.Unlock()
+
+		reconcileServicePorts(svc, []portTuple{keptTuple})
+
+		Expect(kept.closed).To(BeFalse())
+		Expect(dropped.closed).To(BeTrue())
+		Expect(otherSvc.closed).To(BeFalse())
+	})
+})
+
+var _ = Describe("diffIptRules", func() {
+	It("returns only the rules that changed between current and desired", func() {
+		kept := iptRule{table: "nat", chain: iptableNodePortChain, args: []string{"-p", "tcp", "--dport", "80", "-j", "ACCEPT"}}
+		stale := iptRule{table: "nat", chain: iptableNodePortChain, args: []string{"-p", "tcp", "--dport", "81", "-j", "ACCEPT"}}
+		new_ := iptRule{table: "nat", chain: iptableNodePortChain, args: []string{"-p", "tcp", "--dport", "82", "-j", "ACCEPT"}}
+
+		current := [][]string{kept.args, stale.args}
+		desired := []iptRule{kept, new_}
+
+		toAdd, toDel := diffIptRules("nat", iptableNodePortChain, current, desired)
+
+		Expect(toAdd).To(ConsistOf(new_))
+		Expect(toDel).To(ConsistOf(stale))
+	})
+
+	It("returns nothing when current already matches desired", func() {
+		rule := iptRule{table: "filter", chain: iptableNodePortChain, args: []string{"-p", "udp", "--dport", "53", "-j", "ACCEPT"}}
+		toAdd, toDel := diffIptRules("filter", iptableNodePortChain, [][]string{rule.args}, []iptRule{rule})
+		Expect(toAdd).To(BeEmpty())
+		Expect(toDel).To(BeEmpty())
+	})
+})
+
+var _ = Describe("needsLocalnetRules", func() {
+	It("is true for a plain ClusterIP service with spec.ExternalIPs set", func() {
+		svc := &kapi.Service{
+			Spec: kapi.ServiceSpec{
+				Type:        kapi.ServiceTypeClusterIP,
+				ClusterIP:   "10.96.0.12",
+				ExternalIPs: []string{"203.0.113.9"},
+				Ports:       []kapi.ServicePort{{Protocol: kapi.ProtocolTCP, Port: 80}},
+			},
+		}
+		Expect(needsLocalnetRules(svc)).To(BeTrue())
+	})
+
+	It("is false for a plain ClusterIP service with no ExternalIPs", func() {
+		svc := &kapi.Service{
+			Spec: kapi.ServiceSpec{
+				Type:      kapi.ServiceTypeClusterIP,
+				ClusterIP: "10.96.0.13",
+				Ports:     []kapi.ServicePort{{Protocol: kapi.ProtocolTCP, Port: 80}},
+			},
+		}
+		Expect(needsLocalnetRules(svc)).To(BeFalse())
+	})
+
+	It("is true for a ClusterIP service with only an SCTP port and spec.ExternalIPs set", func() {
+		svc := &kapi.Service{
+			Spec: kapi.ServiceSpec{
+				Type:        kapi.ServiceTypeClusterIP,
+				ClusterIP:   "10.96.0.15",
+				ExternalIPs: []string{"203.0.113.11"},
+				Ports:       []kapi.ServicePort{{Protocol: kapi.ProtocolSCTP, Port: 9999}},
+			},
+		}
+		Expect(needsLocalnetRules(svc)).To(BeTrue())
+	})
+})
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/kube"
+)
+
+// ovnNodeL3GatewayConfig is the node annotation key the master watches to
+// discover each node's gateway bridge configuration.
+const ovnNodeL3GatewayConfig = "k8s.ovn.org/l3-gateway-config"
+
+// L3GatewayConfig is the value published under ovnNodeL3GatewayConfig.
+// GatewayIPs and NextHops carry one entry per IP family enabled on the
+// node's gateway bridge, in matching order, so a dual-stack node publishes
+// both its IPv4 and IPv6 gateway addresses for the master to consume.
+type L3GatewayConfig struct {
+	InterfaceID    string   `json:"interface-id"`
+	MACAddress     string   `json:"mac-address"`
+	GatewayIPs     []string `json:"ip-addresses"`
+	NextHops       []string `json:"next-hops"`
+	NodePortEnable bool     `json:"node-port-enable"`
+}
+
+// SetLocalL3GatewayConfig annotates the node with its local gateway bridge
+// configuration. gatewayIPs and nextHops must be non-empty and the same
+// length, with one entry per IP family enabled on the node.
+func SetLocalL3GatewayConfig(nodeAnnotator kube.Annotator, ifaceID string, macAddress net.HardwareAddr,
+	gatewayIPs, nextHops []string, nodePortEnable bool) error {
+	if len(gatewayIPs) == 0 || len(gatewayIPs) != len(nextHops) {
+		return fmt.Errorf("gatewayIPs and nextHops must be non-empty and the same length, got %d and %d",
+			len(gatewayIPs), len(nextHops))
+	}
+	return nodeAnnotator.Set(ovnNodeL3GatewayConfig, L3GatewayConfig{
+		InterfaceID:    ifaceID,
+		MACAddress:     macAddress.String(),
+		GatewayIPs:     gatewayIPs,
+		NextHops:       nextHops,
+		NodePortEnable: nodePortEnable,
+	})
+}